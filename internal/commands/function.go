@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/open-policy-agent/conftest/policy"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// krmResourceList is the input/output envelope of the Kubernetes Resource
+// Model function protocol used by kustomize and kpt:
+// https://github.com/kubernetes-sigs/kustomize/blob/master/cmd/config/docs/api-conventions/functions-spec.md
+type krmResourceList struct {
+	APIVersion     string             `yaml:"apiVersion"`
+	Kind           string             `yaml:"kind"`
+	Items          []interface{}      `yaml:"items"`
+	FunctionConfig *krmFunctionConfig `yaml:"functionConfig,omitempty"`
+	Results        []krmResult        `yaml:"results,omitempty"`
+}
+
+// krmFunctionConfig carries the inline policy configuration so the function
+// is self-contained when invoked by `kustomize fn run` or `kpt fn eval`.
+//
+// Only policy/namespace are supported: conftest has no existing mechanism
+// for loading supplemental data files or naming exceptions from outside a
+// policy (exceptions are already expressed as `exception[rules]` rules in
+// the policy itself), so there's nothing for an inline `data`/`exceptions`
+// list to plug into yet. Narrowing to what's implemented rather than
+// shipping fields that silently do nothing.
+type krmFunctionConfig struct {
+	Data struct {
+		Policy    string   `yaml:"policy"`
+		Namespace []string `yaml:"namespace"`
+	} `yaml:"data"`
+}
+
+// krmResult is a single entry in a ResourceList's `results`.
+type krmResult struct {
+	Message     string          `yaml:"message"`
+	Severity    string          `yaml:"severity"`
+	File        *krmResultFile  `yaml:"file,omitempty"`
+	ResourceRef *krmResourceRef `yaml:"resourceRef,omitempty"`
+}
+
+// krmResultFile locates a krmResult within the manifest tree, per the KRM
+// function spec's result.file.path convention. There's no per-field
+// tracing available (a deny/warn rule only ever returns a message string),
+// so only the path component is populated.
+type krmResultFile struct {
+	Path string `yaml:"path"`
+}
+
+// krmResourceRef identifies the item a krmResult was raised against.
+type krmResourceRef struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// NewFunctionCommand creates the `conftest function` subcommand, which
+// implements the KRM function contract so conftest can run as a step in a
+// kustomize/kpt pipeline: it reads a ResourceList from stdin and writes the
+// same ResourceList, with policy failures attached as `results`, to stdout.
+func NewFunctionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "function",
+		Short: "Run conftest as a Kubernetes Resource Model (KRM) function",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFunction(cmd, os.Stdin, os.Stdout)
+		},
+	}
+
+	return cmd
+}
+
+func runFunction(cmd *cobra.Command, in io.Reader, out io.Writer) error {
+	raw, err := ioutil.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("read ResourceList: %w", err)
+	}
+
+	var list krmResourceList
+	if err := yaml.Unmarshal(raw, &list); err != nil {
+		return fmt.Errorf("unmarshal ResourceList: %w", err)
+	}
+	normalizeYAML(&list.Items)
+
+	if list.FunctionConfig == nil || list.FunctionConfig.Data.Policy == "" {
+		return fmt.Errorf("functionConfig.data.policy is required")
+	}
+
+	regoFiles, err := getFilesFromDirectory(list.FunctionConfig.Data.Policy, "")
+	if err != nil {
+		return fmt.Errorf("load policy %q: %w", list.FunctionConfig.Data.Policy, err)
+	}
+
+	compiler, err := policy.BuildCompiler(regoFiles)
+	if err != nil {
+		return fmt.Errorf("build compiler: %w", err)
+	}
+
+	namespaces := list.FunctionConfig.Data.Namespace
+	if len(namespaces) == 0 {
+		namespaces = []string{"main"}
+	}
+
+	testRun := TestRun{Compiler: compiler, Store: inmem.New()}
+
+	for _, item := range list.Items {
+		path := pathAnnotationOf(item)
+
+		// item is re-marshaled back into the ResourceList below, so the
+		// path annotation is recorded on a shallow copy rather than item
+		// itself — otherwise __inputPath would leak into conftest's own
+		// output.
+		evalDoc := item
+		if path != "" {
+			evalDoc = shallowCopy(item)
+			annotateInputPath(evalDoc, path)
+		}
+
+		result, err := testRun.GetResult(cmd.Context(), namespaces, evalDoc)
+		if err != nil {
+			return fmt.Errorf("evaluate item: %w", err)
+		}
+
+		ref := resourceRefOf(item)
+		var file *krmResultFile
+		if path != "" {
+			file = &krmResultFile{Path: path}
+		}
+
+		for _, f := range result.Failures {
+			list.Results = append(list.Results, krmResult{Message: f.Message, Severity: "error", File: file, ResourceRef: ref})
+		}
+		for _, w := range result.Warnings {
+			list.Results = append(list.Results, krmResult{Message: w.Message, Severity: "warning", File: file, ResourceRef: ref})
+		}
+		for _, e := range result.Exceptions {
+			// Severity "info" here means "an exception rule excused this
+			// finding", not that the policy defined an info[msg] query —
+			// TestRun only recognizes deny/violation and warn queries, there
+			// is no info query concept to map from.
+			list.Results = append(list.Results, krmResult{Message: e.Message, Severity: "info", File: file, ResourceRef: ref})
+		}
+	}
+
+	output, err := yaml.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("marshal ResourceList: %w", err)
+	}
+
+	_, err = out.Write(output)
+	return err
+}
+
+// shallowCopy clones item's top-level map so it can be tagged with
+// __inputPath without mutating the original, which is still destined for
+// conftest's own output.
+func shallowCopy(item interface{}) interface{} {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return item
+	}
+	cp := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+// pathAnnotationOf reads the config.kubernetes.io/path annotation kustomize
+// and kpt attach to every item of a ResourceList, naming the manifest file
+// it was read from. An empty return means the item carries no such
+// annotation (e.g. it wasn't read from a file-backed source).
+func pathAnnotationOf(item interface{}) string {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	metadata, ok := m["metadata"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	path, _ := annotations["config.kubernetes.io/path"].(string)
+	return path
+}
+
+// resourceRefOf extracts enough of a Kubernetes-shaped item to identify it
+// in a krmResult. A nil return just means the result won't carry a ref.
+func resourceRefOf(item interface{}) *krmResourceRef {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	ref := &krmResourceRef{}
+	if v, ok := m["apiVersion"].(string); ok {
+		ref.APIVersion = v
+	}
+	if v, ok := m["kind"].(string); ok {
+		ref.Kind = v
+	}
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		if v, ok := metadata["name"].(string); ok {
+			ref.Name = v
+		}
+		if v, ok := metadata["namespace"].(string); ok {
+			ref.Namespace = v
+		}
+	}
+
+	return ref
+}
+
+// normalizeYAML recursively rewrites the map[interface{}]interface{} values
+// produced by yaml.v2 into map[string]interface{}, matching what the rest of
+// conftest's parsers hand to Rego (which requires JSON-compatible input).
+func normalizeYAML(items *[]interface{}) {
+	for i, item := range *items {
+		(*items)[i] = normalizeYAMLValue(item)
+	}
+}
+
+func normalizeYAMLValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprintf("%v", k)] = normalizeYAMLValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalizeYAMLValue(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAMLValue(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRunFunction(t *testing.T) {
+	resourceList := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: no-replicas
+    annotations:
+      config.kubernetes.io/path: deployments/no-replicas.yaml
+  spec:
+    template:
+      spec:
+        containers:
+        - name: app
+          image: nginx
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: has-replicas
+  spec:
+    replicas: 3
+    template:
+      spec:
+        containers:
+        - name: app
+          image: nginx
+functionConfig:
+  data:
+    policy: ../../examples/overlay/policy
+`
+
+	cmd := &cobra.Command{}
+	var out bytes.Buffer
+
+	if err := runFunction(cmd, strings.NewReader(resourceList), &out); err != nil {
+		t.Fatalf("could not run function: %s", err)
+	}
+
+	const expectedFailures = 1
+	actualFailures := strings.Count(out.String(), "severity: error")
+	if actualFailures != expectedFailures {
+		t.Errorf("KRM function test failure. Got %v error results, expected %v:\n%s", actualFailures, expectedFailures, out.String())
+	}
+
+	var got krmResourceList
+	if err := yaml.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("could not unmarshal output ResourceList: %s", err)
+	}
+
+	var failing *krmResult
+	for i := range got.Results {
+		if got.Results[i].Severity == "error" {
+			failing = &got.Results[i]
+			break
+		}
+	}
+
+	if failing == nil || failing.ResourceRef == nil || failing.ResourceRef.Name != "no-replicas" {
+		t.Errorf("expected the failing result to reference the offending resource, got:\n%s", out.String())
+	}
+
+	if failing == nil || failing.File == nil || failing.File.Path != "deployments/no-replicas.yaml" {
+		t.Errorf("expected the failing result to carry the item's config.kubernetes.io/path as file.path, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,42 @@
+package pb
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// CodecName is the content-subtype under which jsonCodec is registered.
+// Callers must opt into it explicitly (grpc.ForceServerCodec on the server,
+// grpc.CallContentSubtype(CodecName) on the client) rather than relying on
+// it being picked up by default, since "proto" — grpc-go's actual default —
+// must keep meaning real protobuf for every other service in the process.
+const CodecName = "conftest-json"
+
+// jsonCodec implements encoding.Codec by marshaling messages as JSON.
+// EvalRequest/EvalResponse are plain structs rather than generated
+// protobuf types, so this lets them travel over a standard
+// *grpc.Server/ClientConn without a real protobuf toolchain in this
+// checkout.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return CodecName
+}
+
+// Codec is the encoding.Codec used to transport EvalRequest/EvalResponse.
+// Pass it to grpc.ForceServerCodec on the server; clients select it by
+// setting grpc.CallContentSubtype(CodecName) instead.
+var Codec = jsonCodec{}
+
+func init() {
+	encoding.RegisterCodec(Codec)
+}
@@ -0,0 +1,129 @@
+// Package pb holds the Go bindings for proto/conftest/v1/evaluate.proto.
+//
+// This checkout doesn't vendor protoc/protoc-gen-go, so these bindings are
+// maintained by hand instead of generated; keep them in sync with the
+// .proto file by eye until codegen is wired into the build. Messages are
+// carried as plain Go structs and encoded with the JSON codec registered
+// under CodecName in codec.go, rather than real protobuf wire encoding.
+package pb
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// EvalRequest is a single unit of work sent to the Evaluate stream.
+type EvalRequest struct {
+	ParserKind    string            `json:"parser_kind"`
+	RawBytes      []byte            `json:"raw_bytes"`
+	Namespaces    []string          `json:"namespaces"`
+	DataOverrides map[string][]byte `json:"data_overrides"`
+}
+
+// EvalResponse mirrors commands.Result for a single EvalRequest.
+type EvalResponse struct {
+	Failures   []string `json:"failures"`
+	Warnings   []string `json:"warnings"`
+	Successes  []string `json:"successes"`
+	Exceptions []string `json:"exceptions"`
+}
+
+// EvaluateServer is the server API for the Evaluate service.
+type EvaluateServer interface {
+	Evaluate(Evaluate_EvaluateServer) error
+}
+
+// Evaluate_EvaluateServer is the bidirectional stream passed to
+// EvaluateServer.Evaluate.
+type Evaluate_EvaluateServer interface {
+	Send(*EvalResponse) error
+	Recv() (*EvalRequest, error)
+	grpc.ServerStream
+}
+
+type evaluateEvaluateServer struct {
+	grpc.ServerStream
+}
+
+func (x *evaluateEvaluateServer) Send(m *EvalResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *evaluateEvaluateServer) Recv() (*EvalRequest, error) {
+	m := new(EvalRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Evaluate_Evaluate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EvaluateServer).Evaluate(&evaluateEvaluateServer{stream})
+}
+
+// EvaluateServiceDesc is the grpc.ServiceDesc for the Evaluate service.
+var EvaluateServiceDesc = grpc.ServiceDesc{
+	ServiceName: "conftest.v1.Evaluate",
+	HandlerType: (*EvaluateServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Evaluate",
+			Handler:       _Evaluate_Evaluate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "conftest/v1/evaluate.proto",
+}
+
+// RegisterEvaluateServer registers srv with s.
+func RegisterEvaluateServer(s *grpc.Server, srv EvaluateServer) {
+	s.RegisterService(&EvaluateServiceDesc, srv)
+}
+
+// EvaluateClient is the client API for the Evaluate service.
+type EvaluateClient interface {
+	Evaluate(ctx context.Context, opts ...grpc.CallOption) (Evaluate_EvaluateClient, error)
+}
+
+type evaluateClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewEvaluateClient returns an EvaluateClient backed by cc.
+func NewEvaluateClient(cc grpc.ClientConnInterface) EvaluateClient {
+	return &evaluateClient{cc}
+}
+
+func (c *evaluateClient) Evaluate(ctx context.Context, opts ...grpc.CallOption) (Evaluate_EvaluateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EvaluateServiceDesc.Streams[0], "/conftest.v1.Evaluate/Evaluate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &evaluateEvaluateClient{stream}, nil
+}
+
+// Evaluate_EvaluateClient is the bidirectional stream returned by
+// EvaluateClient.Evaluate.
+type Evaluate_EvaluateClient interface {
+	Send(*EvalRequest) error
+	Recv() (*EvalResponse, error)
+	grpc.ClientStream
+}
+
+type evaluateEvaluateClient struct {
+	grpc.ClientStream
+}
+
+func (x *evaluateEvaluateClient) Send(m *EvalRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *evaluateEvaluateClient) Recv() (*EvalResponse, error) {
+	m := new(EvalResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/open-policy-agent/conftest/internal/commands/pb"
+	"github.com/open-policy-agent/conftest/parser/docker"
+	"github.com/open-policy-agent/conftest/parser/yaml"
+	"github.com/open-policy-agent/conftest/policy"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// evaluateServer implements pb.EvaluateServer by compiling its policies once
+// at startup and reusing the compiler and in-memory store across every
+// request. Re-parsing Rego on every call is what makes a cold-started
+// conftest invocation slow, and that cost is what this mode exists to avoid.
+type evaluateServer struct {
+	testRun TestRun
+}
+
+func newEvaluateServer(regoFiles []string) (*evaluateServer, error) {
+	compiler, err := policy.BuildCompiler(regoFiles)
+	if err != nil {
+		return nil, fmt.Errorf("build compiler: %w", err)
+	}
+
+	return &evaluateServer{
+		testRun: TestRun{Compiler: compiler, Store: inmem.New()},
+	}, nil
+}
+
+// Evaluate implements pb.EvaluateServer: it reads documents off the stream
+// until the caller closes it, evaluating each one against the server's
+// already-compiled policies.
+func (s *evaluateServer) Evaluate(stream pb.Evaluate_EvaluateServer) error {
+	ctx := stream.Context()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		doc, err := unmarshalParserKind(req.ParserKind, req.RawBytes)
+		if err != nil {
+			return fmt.Errorf("unmarshal %s input: %w", req.ParserKind, err)
+		}
+
+		testRun := s.testRun
+		if len(req.DataOverrides) > 0 {
+			testRun.Store, err = storeWithDataOverrides(req.DataOverrides)
+			if err != nil {
+				return fmt.Errorf("apply data overrides: %w", err)
+			}
+		}
+
+		result, err := testRun.GetResult(ctx, req.Namespaces, doc)
+		if err != nil {
+			return fmt.Errorf("evaluate input: %w", err)
+		}
+
+		resp := &pb.EvalResponse{}
+		for _, f := range result.Failures {
+			resp.Failures = append(resp.Failures, f.Message)
+		}
+		for _, w := range result.Warnings {
+			resp.Warnings = append(resp.Warnings, w.Message)
+		}
+		for _, o := range result.Successes {
+			resp.Successes = append(resp.Successes, o.Query)
+		}
+		for _, e := range result.Exceptions {
+			resp.Exceptions = append(resp.Exceptions, e.Message)
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// storeWithDataOverrides builds a fresh in-memory store seeded with
+// overrides, one per request: recreating the (cheap) data store doesn't
+// touch the (expensive) compiled policies cached on evaluateServer, so a
+// request's data_overrides never leak into the next request's evaluation.
+func storeWithDataOverrides(overrides map[string][]byte) (storage.Store, error) {
+	data := make(map[string]interface{}, len(overrides))
+
+	for key, raw := range overrides {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("unmarshal data override %q: %w", key, err)
+		}
+		data[key] = value
+	}
+
+	return inmem.NewFromObject(data), nil
+}
+
+// unmarshalParserKind parses raw with the parser named by kind, defaulting
+// to yaml when kind is empty.
+func unmarshalParserKind(kind string, raw []byte) (interface{}, error) {
+	var doc interface{}
+
+	switch kind {
+	case "docker":
+		p := docker.Parser{}
+		if err := p.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+	case "yaml", "":
+		p := yaml.Parser{}
+		if err := p.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown parser kind %q", kind)
+	}
+
+	return doc, nil
+}
+
+// NewServeCommand creates the `conftest serve` subcommand: a gRPC server
+// that keeps its compiled policies warm across requests, for CI agents that
+// would otherwise pay the Rego compile cost on every short-lived
+// invocation.
+func NewServeCommand() *cobra.Command {
+	var addr string
+	var policyDir string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start a gRPC server that evaluates policies against streamed input",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			regoFiles, err := getFilesFromDirectory(policyDir, "")
+			if err != nil {
+				return fmt.Errorf("load policy %q: %w", policyDir, err)
+			}
+
+			srv, err := newEvaluateServer(regoFiles)
+			if err != nil {
+				return err
+			}
+
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("listen on %s: %w", addr, err)
+			}
+
+			s := grpc.NewServer(grpc.ForceServerCodec(pb.Codec))
+			pb.RegisterEvaluateServer(s, srv)
+
+			return s.Serve(lis)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":5595", "address to listen on")
+	cmd.Flags().StringVar(&policyDir, "policy", "policy", "directory to load Rego policies from")
+
+	return cmd
+}
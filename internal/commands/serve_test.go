@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/open-policy-agent/conftest/internal/commands/pb"
+	"google.golang.org/grpc"
+)
+
+func startTestServer(t *testing.T, regoFiles []string) (pb.EvaluateClient, func()) {
+	t.Helper()
+
+	srv, err := newEvaluateServer(regoFiles)
+	if err != nil {
+		t.Fatalf("could not build evaluate server: %s", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+
+	s := grpc.NewServer(grpc.ForceServerCodec(pb.Codec))
+	pb.RegisterEvaluateServer(s, srv)
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("could not dial test server: %s", err)
+	}
+
+	cleanup := func() {
+		conn.Close()
+		s.Stop()
+	}
+
+	return pb.NewEvaluateClient(conn), cleanup
+}
+
+func TestServeEvaluatesKubernetesYaml(t *testing.T) {
+	ctx := context.Background()
+
+	config := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello-kubernetes
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx`
+
+	client, cleanup := startTestServer(t, []string{"../../examples/overlay/policy/overlay.rego"})
+	defer cleanup()
+
+	stream, err := client.Evaluate(ctx, grpc.CallContentSubtype(pb.CodecName))
+	if err != nil {
+		t.Fatalf("could not open evaluate stream: %s", err)
+	}
+
+	if err := stream.Send(&pb.EvalRequest{
+		ParserKind: "yaml",
+		RawBytes:   []byte(config),
+		Namespaces: []string{"main"},
+	}); err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("could not receive response: %s", err)
+	}
+
+	const expectedFailures = 1
+	if len(resp.Failures) != expectedFailures {
+		t.Errorf("Serve test failure. Got %v failures, expected %v", len(resp.Failures), expectedFailures)
+	}
+}
+
+func TestServeEvaluatesDockerfile(t *testing.T) {
+	ctx := context.Background()
+
+	config := `FROM openjdk:8-jdk-alpine
+VOLUME /tmp
+
+ARG DEPENDENCY=target/dependency
+COPY ${DEPENDENCY}/BOOT-INF/lib /app/lib
+COPY ${DEPENDENCY}/META-INF /app/META-INF
+COPY ${DEPENDENCY}/BOOT-INF/classes /app
+
+ENTRYPOINT ["java","-cp","app:app/lib/*","hello.Application"]`
+
+	client, cleanup := startTestServer(t, []string{"../../examples/docker/policy/base.rego"})
+	defer cleanup()
+
+	stream, err := client.Evaluate(ctx, grpc.CallContentSubtype(pb.CodecName))
+	if err != nil {
+		t.Fatalf("could not open evaluate stream: %s", err)
+	}
+
+	if err := stream.Send(&pb.EvalRequest{
+		ParserKind: "docker",
+		RawBytes:   []byte(config),
+		Namespaces: []string{"main"},
+	}); err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("could not receive response: %s", err)
+	}
+
+	const expectedFailures = 1
+	if len(resp.Failures) != expectedFailures {
+		t.Errorf("Serve test failure. Got %v failures, expected %v", len(resp.Failures), expectedFailures)
+	}
+}
+
+// TestServeLoadsPolicyDirectory exercises the same policy-loading path as
+// NewServeCommand's RunE (getFilesFromDirectory followed by
+// newEvaluateServer), rather than handing regoFiles to newEvaluateServer
+// directly, so a regression in that loading path fails this test too.
+func TestServeLoadsPolicyDirectory(t *testing.T) {
+	regoFiles, err := getFilesFromDirectory("../../examples/overlay/policy", "")
+	if err != nil {
+		t.Fatalf("could not load policy directory: %s", err)
+	}
+	if len(regoFiles) == 0 {
+		t.Fatalf("expected at least one Rego file in examples/overlay/policy, got none")
+	}
+
+	if _, err := newEvaluateServer(regoFiles); err != nil {
+		t.Fatalf("could not build evaluate server from loaded policy: %s", err)
+	}
+}
+
+func TestServeEvaluatesDataOverrides(t *testing.T) {
+	ctx := context.Background()
+
+	config := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello-kubernetes
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx`
+
+	client, cleanup := startTestServer(t, []string{"../../examples/overlay/policy/overlay.rego"})
+	defer cleanup()
+
+	stream, err := client.Evaluate(ctx, grpc.CallContentSubtype(pb.CodecName))
+	if err != nil {
+		t.Fatalf("could not open evaluate stream: %s", err)
+	}
+
+	if err := stream.Send(&pb.EvalRequest{
+		ParserKind: "yaml",
+		RawBytes:   []byte(config),
+		Namespaces: []string{"main"},
+		DataOverrides: map[string][]byte{
+			"exempt": []byte(`true`),
+		},
+	}); err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("could not receive response: %s", err)
+	}
+
+	const expectedFailures = 0
+	if len(resp.Failures) != expectedFailures {
+		t.Errorf("Serve test failure. Got %v failures, expected %v; data_overrides was not applied", len(resp.Failures), expectedFailures)
+	}
+}
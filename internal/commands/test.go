@@ -0,0 +1,499 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/conftest/parser/docker"
+	"github.com/open-policy-agent/conftest/parser/overlay"
+	"github.com/open-policy-agent/conftest/parser/yaml"
+	"github.com/open-policy-agent/conftest/policy"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	denyQ = regexp.MustCompile("^(deny|violation)(_[a-zA-Z]+)*$")
+	warnQ = regexp.MustCompile("^warn(_[a-zA-Z]+)*$")
+)
+
+// defaultTimeout bounds a single query evaluation when no --timeout flag is
+// given.
+const defaultTimeout = 5 * time.Minute
+
+// Outcome is a single query result: a failing deny/violation, a warn, or an
+// informational success.
+type Outcome struct {
+	Message   string
+	Namespace string
+	Query     string
+
+	// InputIdentifier names the document this outcome was reported for, see
+	// Exception.InputIdentifier.
+	InputIdentifier string
+}
+
+// Exception is a query that would otherwise have failed, but was excepted by
+// an `exception` rule in the same namespace.
+type Exception struct {
+	Message   string
+	Namespace string
+	Query     string
+
+	// RuleName is the excepted deny/violation rule, qualified by its
+	// namespace, e.g. "main.deny".
+	RuleName string
+	// ExceptionQuery identifies the exception rule that excused RuleName,
+	// e.g. "data.main.exception".
+	ExceptionQuery string
+	// InputIdentifier names the document RuleName was excepted for, derived
+	// from its metadata.name when present.
+	InputIdentifier string
+	// Reason is the free-form explanation returned by a companion
+	// `exception_reason[msg]` rule, if the policy author wrote one.
+	Reason string
+}
+
+// Result is the outcome of running every applicable query, in every
+// namespace, against a single parsed input document.
+type Result struct {
+	Failures   []Outcome
+	Warnings   []Outcome
+	Exceptions []Exception
+	Successes  []Outcome
+}
+
+// TestRun evaluates a compiled set of Rego policies against parsed
+// configuration input.
+type TestRun struct {
+	Compiler *ast.Compiler
+	Store    storage.Store
+
+	// Timeout bounds how long a single query is allowed to run against a
+	// single document. A runaway policy fails that document instead of
+	// hanging the whole test run. Zero means defaultTimeout is used.
+	Timeout time.Duration
+
+	// OverlaySuffix names the sibling file consulted to patch an input
+	// document before it is evaluated (see parser/overlay). Empty means
+	// overlay.DefaultSuffix.
+	OverlaySuffix string
+
+	// OverlayListStrategy controls how an overlay's sequences are
+	// reconciled with the base document's. Empty means overlay.ListReplace.
+	OverlayListStrategy overlay.ListStrategy
+}
+
+// ApplyOverlay patches doc, previously produced by parser for the file at
+// path, with its ".local" overlay, if one exists, honoring t.OverlaySuffix
+// and t.OverlayListStrategy.
+func (t *TestRun) ApplyOverlay(parser overlay.Unmarshaler, path string, doc interface{}) (interface{}, error) {
+	strategy := t.OverlayListStrategy
+	if strategy == "" {
+		strategy = overlay.ListReplace
+	}
+
+	return overlay.Apply(parser, path, doc, t.OverlaySuffix, strategy)
+}
+
+// GetResult runs every deny/violation/warn query defined in namespaces
+// against every document found in input, honoring any exception rules
+// defined alongside them.
+func (t *TestRun) GetResult(ctx context.Context, namespaces []string, input interface{}) (Result, error) {
+	var result Result
+
+	docs, ok := input.([]interface{})
+	if !ok {
+		docs = []interface{}{input}
+	}
+
+	for _, doc := range docs {
+		for _, namespace := range namespaces {
+			exceptionQuery := fmt.Sprintf("data.%s.exception", namespace)
+			excepted, reason, err := t.exceptedRules(ctx, exceptionQuery, doc)
+			if err != nil {
+				return result, err
+			}
+
+			for _, module := range t.Compiler.Modules {
+				if !inNamespace(module, namespace) {
+					continue
+				}
+
+				for _, rule := range module.Rules {
+					ruleName := string(rule.Head.Name)
+
+					var isWarn bool
+					switch {
+					case denyQ.MatchString(ruleName):
+						isWarn = false
+					case warnQ.MatchString(ruleName):
+						isWarn = true
+					default:
+						continue
+					}
+
+					query := fmt.Sprintf("data.%s.%s", namespace, ruleName)
+
+					messages, err := t.evalQuery(ctx, query, doc)
+					if err != nil {
+						var to timeoutError
+						if asTimeout(err, &to) {
+							result.Failures = append(result.Failures, Outcome{
+								Message:         fmt.Sprintf("query %q against %s timed out after %s", query, to.inputPath, to.timeout),
+								Namespace:       namespace,
+								Query:           query,
+								InputIdentifier: inputIdentifierOf(doc),
+							})
+							continue
+						}
+						return result, fmt.Errorf("evaluate query %q: %w", query, err)
+					}
+
+					if len(messages) == 0 {
+						result.Successes = append(result.Successes, Outcome{Namespace: namespace, Query: query, InputIdentifier: inputIdentifierOf(doc)})
+						continue
+					}
+
+					for _, m := range messages {
+						fullRuleName := fmt.Sprintf("%s.%s", namespace, ruleName)
+						if !isWarn && excepted[fullRuleName] {
+							result.Exceptions = append(result.Exceptions, Exception{
+								Message:         fmt.Sprintf("%v", m),
+								Namespace:       namespace,
+								Query:           query,
+								RuleName:        fullRuleName,
+								ExceptionQuery:  exceptionQuery,
+								InputIdentifier: inputIdentifierOf(doc),
+								Reason:          reason,
+							})
+							continue
+						}
+
+						outcome := Outcome{
+							Message:         fmt.Sprintf("%v", m),
+							Namespace:       namespace,
+							Query:           query,
+							InputIdentifier: inputIdentifierOf(doc),
+						}
+						if isWarn {
+							result.Warnings = append(result.Warnings, outcome)
+						} else {
+							result.Failures = append(result.Failures, outcome)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// exceptedRules evaluates exceptionQuery (the namespace's `exception` rule,
+// if any) against doc, returning the set of fully qualified rule names
+// (e.g. "main.deny") that doc is excused from, plus the reason for the
+// exception as reported by a companion `exception_reason[msg]` rule, if the
+// policy author wrote one.
+func (t *TestRun) exceptedRules(ctx context.Context, exceptionQuery string, doc interface{}) (map[string]bool, string, error) {
+	excepted := map[string]bool{}
+
+	messages, err := t.evalQuery(ctx, exceptionQuery, doc)
+	if err != nil {
+		var to timeoutError
+		if asTimeout(err, &to) {
+			return excepted, "", nil
+		}
+		// A namespace without an `exception` rule is the common case: an
+		// undefined query surfaces as an empty result set, not an error, so
+		// reaching here means the `exception` rule itself is broken and
+		// should fail loudly rather than be treated as "no exceptions".
+		return excepted, "", fmt.Errorf("evaluate exception query %q: %w", exceptionQuery, err)
+	}
+
+	for _, m := range messages {
+		rules, ok := m.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, r := range rules {
+			if name, ok := r.(string); ok {
+				excepted[name] = true
+			}
+		}
+	}
+
+	if len(excepted) == 0 {
+		return excepted, "", nil
+	}
+
+	reasonQuery := strings.TrimSuffix(exceptionQuery, "exception") + "exception_reason"
+	reasons, err := t.evalQuery(ctx, reasonQuery, doc)
+	if err != nil {
+		// exception_reason is optional; treat any failure to evaluate it
+		// (including a timeout) the same as the rule being undefined.
+		return excepted, "", nil
+	}
+	if len(reasons) > 0 {
+		return excepted, fmt.Sprintf("%v", reasons[0]), nil
+	}
+
+	return excepted, "", nil
+}
+
+// inputIdentifierOf names doc for reporting purposes, preferring its
+// Kubernetes-style metadata.name when present.
+func inputIdentifierOf(doc interface{}) string {
+	if m, ok := doc.(map[string]interface{}); ok {
+		if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+			if name, ok := metadata["name"].(string); ok {
+				return name
+			}
+		}
+	}
+	return inputPathOf(doc)
+}
+
+// timeoutError describes a single query/document pair that failed to
+// complete within TestRun.Timeout.
+type timeoutError struct {
+	query     string
+	inputPath string
+	timeout   time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("query %q timed out after %s", e.query, e.timeout)
+}
+
+func asTimeout(err error, target *timeoutError) bool {
+	te, ok := err.(*timeoutError)
+	if !ok {
+		return false
+	}
+	*target = *te
+	return true
+}
+
+func inputPathOf(doc interface{}) string {
+	if m, ok := doc.(map[string]interface{}); ok {
+		if p, ok := m["__inputPath"].(string); ok {
+			return p
+		}
+	}
+	return "<stdin>"
+}
+
+// annotateInputPath records path as doc's __inputPath, so a later timeout or
+// other failure can name the file it came from instead of falling back to
+// "<stdin>". doc is tagged in place when it's a single document; when it's a
+// slice of documents (a multi-document YAML file, or a KRM ResourceList's
+// items), every element is tagged the same way. A doc that isn't a map is
+// left untouched — there's no field on it to carry the annotation.
+func annotateInputPath(doc interface{}, path string) {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		d["__inputPath"] = path
+	case []interface{}:
+		for _, elem := range d {
+			annotateInputPath(elem, path)
+		}
+	}
+}
+
+func inNamespace(module *ast.Module, namespace string) bool {
+	path := module.Package.Path
+	last, ok := path[len(path)-1].Value.(ast.String)
+	return ok && string(last) == namespace
+}
+
+// evalQuery runs query against doc, bounded by t.Timeout, and returns the
+// matched values (e.g. the messages returned by a deny/violation/warn rule).
+func (t *TestRun) evalQuery(ctx context.Context, query string, doc interface{}) ([]interface{}, error) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	evalCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rs, err := rego.New(
+		rego.Query(query),
+		rego.Compiler(t.Compiler),
+		rego.Store(t.Store),
+		rego.Input(doc),
+	).Eval(evalCtx)
+	if err != nil {
+		if evalCtx.Err() == context.DeadlineExceeded {
+			return nil, &timeoutError{query: query, inputPath: inputPathOf(doc), timeout: timeout}
+		}
+		return nil, err
+	}
+
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, nil
+	}
+
+	values, ok := rs[0].Expressions[0].Value.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	return values, nil
+}
+
+// getFilesFromDirectory walks path and returns every file whose name does
+// not match exclude. An empty exclude excludes nothing.
+func getFilesFromDirectory(path string, exclude string) ([]string, error) {
+	var files []string
+
+	var excludeRegex *regexp.Regexp
+	if exclude != "" {
+		var err error
+		excludeRegex, err = regexp.Compile(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("compile exclude regex: %w", err)
+		}
+	}
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if excludeRegex != nil && excludeRegex.MatchString(p) {
+			return nil
+		}
+
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// parserForPath picks the parser.Parser-shaped Unmarshaler to use for path,
+// based on its filename.
+func parserForPath(path string) overlay.Unmarshaler {
+	if strings.HasPrefix(filepath.Base(path), "Dockerfile") {
+		return docker.Parser{}
+	}
+	return yaml.Parser{}
+}
+
+// NewTestCommand creates the `conftest test` subcommand.
+func NewTestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test <path> [path...]",
+		Short: "Test your configuration files using Open Policy Agent",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("the path to at least one file is required")
+			}
+
+			regoFiles, err := getFilesFromDirectory(viper.GetString("policy"), "")
+			if err != nil {
+				return fmt.Errorf("load policy %q: %w", viper.GetString("policy"), err)
+			}
+
+			compiler, err := policy.BuildCompiler(regoFiles)
+			if err != nil {
+				return fmt.Errorf("build compiler: %w", err)
+			}
+
+			testRun := TestRun{
+				Compiler:            compiler,
+				Store:               inmem.New(),
+				Timeout:             viper.GetDuration("timeout"),
+				OverlaySuffix:       viper.GetString("overlay-suffix"),
+				OverlayListStrategy: overlay.ListStrategy(viper.GetString("overlay-list-strategy")),
+			}
+
+			applyOverlay := viper.GetBool("overlay")
+
+			namespaces := viper.GetStringSlice("namespace")
+			if len(namespaces) == 0 {
+				namespaces = []string{"main"}
+			}
+
+			var failures int
+			for _, path := range args {
+				raw, err := ioutil.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("read %q: %w", path, err)
+				}
+
+				parser := parserForPath(path)
+
+				var doc interface{}
+				if err := parser.Unmarshal(raw, &doc); err != nil {
+					return fmt.Errorf("parse %q: %w", path, err)
+				}
+
+				if applyOverlay {
+					doc, err = testRun.ApplyOverlay(parser, path, doc)
+					if err != nil {
+						return fmt.Errorf("apply overlay for %q: %w", path, err)
+					}
+				}
+				annotateInputPath(doc, path)
+
+				result, err := testRun.GetResult(cmd.Context(), namespaces, doc)
+				if err != nil {
+					return fmt.Errorf("evaluate %q: %w", path, err)
+				}
+
+				for _, f := range result.Failures {
+					fmt.Fprintf(cmd.OutOrStdout(), "FAIL - %s - %s\n", path, f.Message)
+					failures++
+				}
+				for _, w := range result.Warnings {
+					fmt.Fprintf(cmd.OutOrStdout(), "WARN - %s - %s\n", path, w.Message)
+				}
+			}
+
+			if failures > 0 {
+				return fmt.Errorf("%d policy failure(s)", failures)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Duration("timeout", defaultTimeout, "timeout for a single policy query against a single input document")
+	_ = viper.BindPFlag("timeout", cmd.Flags().Lookup("timeout"))
+
+	cmd.Flags().String("policy", "policy", "directory to load Rego policies from")
+	_ = viper.BindPFlag("policy", cmd.Flags().Lookup("policy"))
+
+	cmd.Flags().StringSlice("namespace", []string{"main"}, "namespace(s) to test input against")
+	_ = viper.BindPFlag("namespace", cmd.Flags().Lookup("namespace"))
+
+	cmd.Flags().Bool("overlay", false, "patch each input with a sibling \"<file>.local\" overlay before testing it")
+	_ = viper.BindPFlag("overlay", cmd.Flags().Lookup("overlay"))
+
+	cmd.Flags().String("overlay-suffix", overlay.DefaultSuffix, "suffix used to find an input's overlay file")
+	_ = viper.BindPFlag("overlay-suffix", cmd.Flags().Lookup("overlay-suffix"))
+
+	cmd.Flags().String("overlay-list-strategy", string(overlay.ListReplace), "how to reconcile sequences between an input and its overlay (replace|append)")
+	_ = viper.BindPFlag("overlay-list-strategy", cmd.Flags().Lookup("overlay-list-strategy"))
+
+	return cmd
+}
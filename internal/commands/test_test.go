@@ -5,9 +5,12 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/open-policy-agent/conftest/parser/docker"
+	"github.com/open-policy-agent/conftest/parser/overlay"
 	"github.com/open-policy-agent/conftest/parser/yaml"
 	"github.com/open-policy-agent/conftest/policy"
 	"github.com/open-policy-agent/opa/storage/inmem"
@@ -140,6 +143,181 @@ spec:
 	if actualExceptions != expectedExceptions {
 		t.Errorf("Multifile yaml test failure. Got %v exceptions, expected %v", actualExceptions, expectedExceptions)
 	}
+
+	if actualExceptions > 0 {
+		exception := results.Exceptions[0]
+
+		if exception.InputIdentifier != "can-run-as-root" {
+			t.Errorf("expected the exception to be reported against can-run-as-root, got: %q", exception.InputIdentifier)
+		}
+		if exception.RuleName != "main.deny" {
+			t.Errorf("expected the excepted rule to be main.deny, got: %q", exception.RuleName)
+		}
+		if exception.ExceptionQuery != "data.main.exception" {
+			t.Errorf("expected the exception query to be data.main.exception, got: %q", exception.ExceptionQuery)
+		}
+		if exception.Reason == "" {
+			t.Errorf("expected exception_reason[msg] to populate Reason, got an empty string")
+		}
+	}
+
+	if actualFailures > 0 && results.Failures[0].InputIdentifier != "cannot-run-as-root" {
+		t.Errorf("expected the remaining failure to be reported for cannot-run-as-root, got: %q", results.Failures[0].InputIdentifier)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	config := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello-kubernetes`
+
+	yaml := yaml.Parser{}
+
+	var manifest interface{}
+	err := yaml.Unmarshal([]byte(config), &manifest)
+	if err != nil {
+		t.Fatalf("could not unmarshal yaml: %s", err)
+	}
+	const inputPath = "testdata/hello-kubernetes.yaml"
+	annotateInputPath(manifest, inputPath)
+
+	regoFiles := []string{"../../examples/timeout/policy/slow.rego"}
+	compiler, err := policy.BuildCompiler(regoFiles)
+	if err != nil {
+		t.Fatalf("could not build rego compiler: %s", err)
+	}
+
+	testRun := TestRun{
+		Compiler: compiler,
+		Store:    inmem.New(),
+		Timeout:  10 * time.Millisecond,
+	}
+
+	defaultNamespace := []string{"main"}
+	results, err := testRun.GetResult(ctx, defaultNamespace, manifest)
+	if err != nil {
+		t.Fatalf("GetResult returned an error instead of a timeout failure: %s", err)
+	}
+
+	const expectedFailures = 1
+	actualFailures := len(results.Failures)
+	if actualFailures != expectedFailures {
+		t.Errorf("Timeout test failure. Got %v failures, expected %v", actualFailures, expectedFailures)
+	}
+
+	if actualFailures > 0 && !strings.Contains(results.Failures[0].Message, "timed out") {
+		t.Errorf("expected a timeout failure message, got: %s", results.Failures[0].Message)
+	}
+
+	if actualFailures > 0 && !strings.Contains(results.Failures[0].Message, inputPath) {
+		t.Errorf("expected the timeout failure to name %q, got: %s", inputPath, results.Failures[0].Message)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	ctx := context.Background()
+
+	base := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello-kubernetes
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx`
+
+	dir, err := ioutil.TempDir("", "overlay-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	basePath := dir + "/deployment.yaml"
+	if err := ioutil.WriteFile(basePath, []byte(base), 0644); err != nil {
+		t.Fatalf("could not write base file: %s", err)
+	}
+	if err := ioutil.WriteFile(basePath+overlay.DefaultSuffix, []byte(`spec:
+  replicas: 3`), 0644); err != nil {
+		t.Fatalf("could not write overlay file: %s", err)
+	}
+
+	yamlParser := yaml.Parser{}
+
+	var manifest interface{}
+	if err := yamlParser.Unmarshal([]byte(base), &manifest); err != nil {
+		t.Fatalf("could not unmarshal yaml: %s", err)
+	}
+
+	regoFiles := []string{"../../examples/overlay/policy/overlay.rego"}
+	compiler, err := policy.BuildCompiler(regoFiles)
+	if err != nil {
+		t.Fatalf("could not build rego compiler: %s", err)
+	}
+
+	testRun := TestRun{
+		Compiler: compiler,
+		Store:    inmem.New(),
+	}
+
+	defaultNamespace := []string{"main"}
+
+	results, err := testRun.GetResult(ctx, defaultNamespace, manifest)
+	if err != nil {
+		t.Fatalf("could not process policy file: %s", err)
+	}
+	if len(results.Failures) != 1 {
+		t.Errorf("Overlay test failure. Got %v failures without the overlay applied, expected 1", len(results.Failures))
+	}
+
+	patched, err := testRun.ApplyOverlay(yamlParser, basePath, manifest)
+	if err != nil {
+		t.Fatalf("could not apply overlay: %s", err)
+	}
+
+	results, err = testRun.GetResult(ctx, defaultNamespace, patched)
+	if err != nil {
+		t.Fatalf("could not process policy file: %s", err)
+	}
+	if len(results.Failures) != 0 {
+		t.Errorf("Overlay test failure. Got %v failures with the overlay applied, expected 0", len(results.Failures))
+	}
+}
+
+func TestExceptionRuleError(t *testing.T) {
+	ctx := context.Background()
+
+	config := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello-kubernetes`
+
+	yaml := yaml.Parser{}
+
+	var manifest interface{}
+	if err := yaml.Unmarshal([]byte(config), &manifest); err != nil {
+		t.Fatalf("could not unmarshal yaml: %s", err)
+	}
+
+	regoFiles := []string{"../../examples/overlay/policy/overlay.rego", "../../examples/exceptions-broken/policy/exception.rego"}
+	compiler, err := policy.BuildCompiler(regoFiles)
+	if err != nil {
+		t.Fatalf("could not build rego compiler: %s", err)
+	}
+
+	testRun := TestRun{
+		Compiler: compiler,
+		Store:    inmem.New(),
+	}
+
+	defaultNamespace := []string{"main"}
+	if _, err := testRun.GetResult(ctx, defaultNamespace, manifest); err == nil {
+		t.Fatalf("expected GetResult to surface a broken exception rule as an error, got nil")
+	}
 }
 
 func TestMultifileYaml(t *testing.T) {
@@ -273,6 +451,7 @@ func TestGetFilesFromDirectory(t *testing.T) {
 		{".*.tf", []string{"test/parent/file1.yaml"}},
 		{"child/", []string{"test/file1.tf", "test/file2.tf", "test/parent/file1.tf", "test/parent/file1.yaml"}},
 		{"parent/", []string{"test/file1.tf", "test/file2.tf"}},
+		{"", []string{"test/file1.tf", "test/file2.tf", "test/parent/child/test.tf", "test/parent/file1.tf", "test/parent/file1.yaml"}},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,104 @@
+// Package overlay implements a crowdsec-style ".local" overlay mechanism:
+// once a file has been parsed, a sibling "<name>.<ext>.local" file (or a
+// configurable suffix) is parsed the same way and deep-merged into it before
+// the combined document is handed to policy evaluation.
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// ListStrategy controls how Merge reconciles two sequences found at the same
+// path in the base and overlay documents.
+type ListStrategy string
+
+const (
+	// ListReplace makes the overlay sequence replace the base sequence
+	// wholesale. This is the default.
+	ListReplace ListStrategy = "replace"
+	// ListAppend appends the overlay sequence's elements to the base
+	// sequence's.
+	ListAppend ListStrategy = "append"
+)
+
+// DefaultSuffix is appended to a parsed file's path to find its overlay,
+// e.g. "deployment.yaml" -> "deployment.yaml.local".
+const DefaultSuffix = ".local"
+
+// Unmarshaler matches the Unmarshal method already implemented by every
+// parser.Parser, so Apply can parse an overlay file with whichever parser
+// produced base, without importing that parser package directly.
+type Unmarshaler interface {
+	Unmarshal(p []byte, v interface{}) error
+}
+
+// Path returns the overlay file sibling to path. An empty suffix falls back
+// to DefaultSuffix.
+func Path(path string, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultSuffix
+	}
+	return path + suffix
+}
+
+// Apply looks for the overlay sibling of path and, if present, deep-merges
+// it into base using strategy for sequences. base is returned unmodified
+// when no overlay file exists.
+func Apply(parser Unmarshaler, path string, base interface{}, suffix string, strategy ListStrategy) (interface{}, error) {
+	overlayPath := Path(path, suffix)
+
+	raw, err := ioutil.ReadFile(overlayPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return base, nil
+		}
+		return nil, fmt.Errorf("read overlay %q: %w", overlayPath, err)
+	}
+
+	var patch interface{}
+	if err := parser.Unmarshal(raw, &patch); err != nil {
+		return nil, fmt.Errorf("unmarshal overlay %q: %w", overlayPath, err)
+	}
+
+	return Merge(base, patch, strategy), nil
+}
+
+// Merge deep-merges patch into base: maps are merged key-wise recursively,
+// scalars in patch replace the corresponding value in base, and sequences
+// are combined according to strategy.
+func Merge(base, patch interface{}, strategy ListStrategy) interface{} {
+	if baseMap, ok := base.(map[string]interface{}); ok {
+		if patchMap, ok := patch.(map[string]interface{}); ok {
+			merged := make(map[string]interface{}, len(baseMap))
+			for k, v := range baseMap {
+				merged[k] = v
+			}
+			for k, pv := range patchMap {
+				if bv, ok := merged[k]; ok {
+					merged[k] = Merge(bv, pv, strategy)
+				} else {
+					merged[k] = pv
+				}
+			}
+			return merged
+		}
+		return patch
+	}
+
+	if baseSlice, ok := base.([]interface{}); ok {
+		if patchSlice, ok := patch.([]interface{}); ok {
+			if strategy == ListAppend {
+				combined := make([]interface{}, 0, len(baseSlice)+len(patchSlice))
+				combined = append(combined, baseSlice...)
+				combined = append(combined, patchSlice...)
+				return combined
+			}
+			return patchSlice
+		}
+		return patch
+	}
+
+	return patch
+}
@@ -0,0 +1,118 @@
+package overlay
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type yamlParser struct{}
+
+func (yamlParser) Unmarshal(p []byte, v interface{}) error {
+	// The real yaml parser is out of scope for this package; these tests
+	// only exercise JSON-shaped maps (valid YAML), so a minimal stand-in is
+	// enough.
+	return json.Unmarshal(p, v)
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     interface{}
+		patch    interface{}
+		strategy ListStrategy
+		exp      interface{}
+	}{
+		{
+			name:     "scalar replace",
+			base:     map[string]interface{}{"replicas": float64(1)},
+			patch:    map[string]interface{}{"replicas": float64(3)},
+			strategy: ListReplace,
+			exp:      map[string]interface{}{"replicas": float64(3)},
+		},
+		{
+			name: "nested map merge",
+			base: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "app", "labels": map[string]interface{}{"a": "1"}},
+			},
+			patch: map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"b": "2"}},
+			},
+			strategy: ListReplace,
+			exp: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "app", "labels": map[string]interface{}{"a": "1", "b": "2"}},
+			},
+		},
+		{
+			name:     "list replace",
+			base:     map[string]interface{}{"ports": []interface{}{float64(80)}},
+			patch:    map[string]interface{}{"ports": []interface{}{float64(443)}},
+			strategy: ListReplace,
+			exp:      map[string]interface{}{"ports": []interface{}{float64(443)}},
+		},
+		{
+			name:     "list append",
+			base:     map[string]interface{}{"ports": []interface{}{float64(80)}},
+			patch:    map[string]interface{}{"ports": []interface{}{float64(443)}},
+			strategy: ListAppend,
+			exp:      map[string]interface{}{"ports": []interface{}{float64(80), float64(443)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Merge(tt.base, tt.patch, tt.strategy)
+			if !reflect.DeepEqual(tt.exp, res) {
+				t.Errorf("expected: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "deployment.yaml")
+	if err := ioutil.WriteFile(path+".local", []byte(`{"replicas": 3}`), 0644); err != nil {
+		t.Fatalf("could not write overlay file: %s", err)
+	}
+
+	base := map[string]interface{}{"replicas": float64(1)}
+
+	merged, err := Apply(yamlParser{}, path, base, "", ListReplace)
+	if err != nil {
+		t.Fatalf("could not apply overlay: %s", err)
+	}
+
+	exp := map[string]interface{}{"replicas": float64(3)}
+	if !reflect.DeepEqual(exp, merged) {
+		t.Errorf("expected: %v, got: %v", exp, merged)
+	}
+}
+
+func TestApplyNoOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "deployment.yaml")
+	base := map[string]interface{}{"replicas": float64(1)}
+
+	merged, err := Apply(yamlParser{}, path, base, "", ListReplace)
+	if err != nil {
+		t.Fatalf("could not apply overlay: %s", err)
+	}
+
+	if !reflect.DeepEqual(base, merged) {
+		t.Errorf("expected unmodified base, got: %v", merged)
+	}
+}
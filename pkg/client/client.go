@@ -0,0 +1,63 @@
+// Package client provides a small wrapper around pb.EvaluateClient for CI
+// agents that want to stream documents at a long-running `conftest serve`
+// instance instead of invoking the CLI per-document.
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-policy-agent/conftest/internal/commands/pb"
+	"google.golang.org/grpc"
+)
+
+// Client evaluates documents against a running conftest server.
+type Client struct {
+	conn   *grpc.ClientConn
+	client pb.EvaluateClient
+}
+
+// Dial connects to a conftest server listening at addr.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	return &Client{conn: conn, client: pb.NewEvaluateClient(conn)}, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Evaluate opens an Evaluate stream and sends every request in reqs,
+// returning one response per request in the same order.
+func (c *Client) Evaluate(ctx context.Context, reqs []*pb.EvalRequest) ([]*pb.EvalResponse, error) {
+	stream, err := c.client.Evaluate(ctx, grpc.CallContentSubtype(pb.CodecName))
+	if err != nil {
+		return nil, fmt.Errorf("open evaluate stream: %w", err)
+	}
+
+	responses := make([]*pb.EvalResponse, 0, len(reqs))
+
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("send request: %w", err)
+		}
+
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("receive response: %w", err)
+		}
+
+		responses = append(responses, resp)
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, fmt.Errorf("close stream: %w", err)
+	}
+
+	return responses, nil
+}